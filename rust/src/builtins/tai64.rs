@@ -30,6 +30,7 @@ impl Message for TAI64N {
 
         decoder.decode_expected_header(&mut bytes, 1, WireType::UInt64)?;
         let nanos = decoder.decode_uint64(&mut bytes)?;
+        decoder.finish(bytes)?;
 
         if nanos > core::u32::MAX as u64 {
             return Err(Error::Length);
@@ -62,3 +63,18 @@ fn tai64_to_ints(tai64n: &TAI64N) -> (u64, u32) {
     let nanos = u32::from_le_bytes(encoded[8..].try_into().unwrap());
     (secs, nanos)
 }
+
+#[cfg(test)]
+mod tests {
+    use super::TAI64N;
+    use crate::{error::Error, message::Message};
+
+    #[test]
+    fn decode_duplicate_field() {
+        let encoded = [0x05, 0x03, 0x05, 0x03];
+        assert_eq!(
+            TAI64N::decode(encoded).err().unwrap(),
+            Error::Duplicate { tag: 0 }
+        );
+    }
+}
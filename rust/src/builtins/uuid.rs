@@ -24,8 +24,9 @@ impl Message for Uuid {
         let mut bytes = bytes.as_ref();
         let mut decoder = Decoder::new();
 
-        decoder.decode_expected_header(&mut bytes, 0, WireType::String)?;
+        decoder.decode_expected_header(&mut bytes, 0, WireType::Bytes)?;
         let uuid_bytes = decoder.decode_bytes(&mut bytes)?;
+        decoder.finish(bytes)?;
 
         uuid_bytes
             .try_into()
@@ -43,3 +44,52 @@ impl Message for Uuid {
         field::length::bytes(0, self.as_bytes())
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::Uuid;
+    use crate::{error::Error, message::Message};
+
+    const EXAMPLE_BYTES: [u8; 16] = [
+        0x67, 0xe5, 0x50, 0x44, 0x10, 0xb1, 0x42, 0x6f, 0x92, 0x47, 0xbb, 0x68, 0x0e, 0x5f, 0xe0,
+        0xc8,
+    ];
+
+    #[test]
+    fn round_trip() {
+        let uuid = Uuid::from_bytes(EXAMPLE_BYTES);
+        let mut buffer = [0u8; 32];
+        let encoded = uuid.encode(&mut buffer).unwrap();
+
+        assert_eq!(encoded.len(), uuid.encoded_len());
+        assert_eq!(Uuid::decode(encoded).unwrap(), uuid);
+    }
+
+    #[test]
+    fn decode_wrong_length() {
+        let encoded = [25, 7, 1, 2, 3];
+        assert_eq!(Uuid::decode(encoded).err().unwrap(), Error::Builtin);
+    }
+
+    #[test]
+    fn decode_malformed_header() {
+        let encoded = [0x02, 0x00];
+        assert_eq!(Uuid::decode(encoded).err().unwrap(), Error::VInt64);
+    }
+
+    #[test]
+    fn decode_duplicate_field() {
+        let mut encoded = [0u8; 36];
+        let len = Uuid::from_bytes(EXAMPLE_BYTES)
+            .encode(&mut encoded)
+            .unwrap()
+            .len();
+        encoded.copy_within(..len, len);
+
+        // The repeated field is left over once the UUID has been decoded
+        assert_eq!(
+            Uuid::decode(&encoded[..]).err().unwrap(),
+            Error::TrailingData
+        );
+    }
+}
@@ -5,6 +5,20 @@ pub mod sequence;
 
 mod decodable;
 mod event;
+mod explain;
+mod extract;
+mod fields;
+mod limits;
+mod value;
 mod vint64;
 
-pub use self::{decodable::Decodable, event::Event, message::Decoder};
+pub use self::{
+    decodable::Decodable,
+    event::Event,
+    explain::{explain, explain_with_limits},
+    extract::{extract, extract_with_limits},
+    fields::Fields,
+    limits::Limits,
+    message::Decoder,
+    value::Value,
+};
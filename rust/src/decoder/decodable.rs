@@ -18,14 +18,18 @@ pub trait Decodable {
         input: &mut &'a [u8],
     ) -> Result<&'a [u8], Error>;
 
+    /// Put the decoder into a failed state, so all subsequent calls return
+    /// [`Error::Failed`], and return the given error for the caller
+    fn fail(&mut self, error: Error) -> Error;
+
     /// Decode an expected `uint64`, returning an error for anything else
     fn decode_uint64(&mut self, input: &mut &[u8]) -> Result<u64, Error> {
         match self.decode(input)? {
             Some(Event::UInt64(value)) => Ok(value),
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::Value,
                 wire_type: WireType::UInt64,
-            }),
+            })),
         }
     }
 
@@ -33,10 +37,10 @@ pub trait Decodable {
     fn decode_sint64(&mut self, input: &mut &[u8]) -> Result<i64, Error> {
         match self.decode(input)? {
             Some(Event::SInt64(value)) => Ok(value),
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::Value,
                 wire_type: WireType::SInt64,
-            }),
+            })),
         }
     }
 
@@ -48,8 +52,10 @@ pub trait Decodable {
     /// Decode an expected `string` field, returning an error for anything else
     fn decode_string<'a>(&mut self, input: &mut &'a [u8]) -> Result<&'a str, Error> {
         let bytes = self.decode_dynamically_sized_value(WireType::String, input)?;
-        str::from_utf8(bytes).map_err(|e| Error::Utf8 {
-            valid_up_to: e.valid_up_to(),
+        str::from_utf8(bytes).map_err(|e| {
+            self.fail(Error::Utf8 {
+                valid_up_to: e.valid_up_to(),
+            })
         })
     }
 
@@ -69,10 +75,10 @@ pub trait Decodable {
                 length
             }
             _ => {
-                return Err(Error::Decode {
+                return Err(self.fail(Error::Decode {
                     element: Element::SequenceHeader,
                     wire_type: expected_type,
-                })
+                }))
             }
         };
 
@@ -84,16 +90,16 @@ pub trait Decodable {
                     debug_assert_eq!(length, bytes.len());
                     Ok(bytes)
                 } else {
-                    Err(Error::Truncated {
+                    Err(self.fail(Error::Truncated {
                         remaining,
                         wire_type: WireType::Sequence,
-                    })
+                    }))
                 }
             }
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::Value,
                 wire_type: WireType::Sequence,
-            }),
+            })),
         }
     }
 }
@@ -0,0 +1,330 @@
+//! Annotated descriptions of encoded messages, for debugging
+
+use super::{sequence, Decodable, Decoder, Event, Limits, Value};
+use crate::{error::Error, field::WireType, message::Element};
+use core::fmt::{self, Write};
+
+/// Width of the column containing encoded field headers
+const HEADER_WIDTH: usize = vint64::MAX_BYTES * 2;
+
+/// Maximum number of bytes of binary data shown in a value preview
+const PREVIEW_BYTES: usize = 16;
+
+/// Maximum number of characters of a string shown in a value preview
+const PREVIEW_CHARS: usize = 32;
+
+/// Write a description of an encoded message to `out`, one field per line.
+///
+/// Each line gives the field's absolute offset in `message`, its encoded
+/// header, tag, wire type, declared length (for dynamically sized values)
+/// and a preview of its value. The contents of nested messages and
+/// sequences are described recursively.
+///
+/// If `message` is malformed, the fields preceding the error are written
+/// before the error is returned. Values nested more deeply than
+/// [`Limits::max_depth`] allows are rejected with [`Error::Length`].
+pub fn explain(message: &[u8], out: &mut dyn Write) -> Result<(), Error> {
+    explain_with_limits(message, out, Limits::default())
+}
+
+/// Write a description of an encoded message like [`explain`], enforcing
+/// the given [`Limits`] on it and on any nested messages
+pub fn explain_with_limits(
+    message: &[u8],
+    out: &mut dyn Write,
+    limits: Limits,
+) -> Result<(), Error> {
+    Explainer { out, limits }.message(message, 0, 0)
+}
+
+/// Decode a sequence nested inside of another sequence
+fn nested_sequence<'a>(
+    decoder: &mut sequence::Decoder,
+    input: &mut &'a [u8],
+) -> Result<Value<'a>, Error> {
+    let wire_type = match decoder.decode(input)? {
+        Some(Event::SequenceHeader { wire_type, .. }) => wire_type,
+        _ => {
+            return Err(Error::Decode {
+                element: Element::SequenceHeader,
+                wire_type: WireType::Sequence,
+            })
+        }
+    };
+
+    match decoder.decode(input)? {
+        Some(Event::ValueChunk {
+            bytes,
+            remaining: 0,
+            ..
+        }) => Ok(Value::Sequence { wire_type, bytes }),
+        Some(Event::ValueChunk { remaining, .. }) => Err(Error::Truncated {
+            remaining,
+            wire_type: WireType::Sequence,
+        }),
+        _ => Err(Error::Decode {
+            element: Element::Value,
+            wire_type: WireType::Sequence,
+        }),
+    }
+}
+
+/// Writes descriptions of messages
+struct Explainer<'w> {
+    /// Output to write the description to
+    out: &'w mut dyn Write,
+
+    /// Limits to enforce on each message being described
+    limits: Limits,
+}
+
+impl<'w> Explainer<'w> {
+    /// Describe the fields of a message located at `offset`
+    fn message(&mut self, mut input: &[u8], offset: usize, depth: usize) -> Result<(), Error> {
+        let end = offset + input.len();
+        let mut decoder = Decoder::with_limits(self.limits);
+
+        while !input.is_empty() {
+            let field_offset = end - input.len();
+            let field = input;
+            let header = decoder.decode_header(&mut input)?;
+            let header_bytes = &field[..field.len() - input.len()];
+            let value = decoder.decode_value(header.wire_type, &mut input)?;
+
+            self.offset(field_offset)?;
+            self.header(header_bytes)?;
+            self.indent(depth)?;
+            write!(self, "[{}] ", header.tag)?;
+
+            if header.critical {
+                write!(self, "!")?;
+            }
+
+            self.value(&value, end - input.len(), depth)?;
+        }
+
+        Ok(())
+    }
+
+    /// Describe the values of a sequence located at `offset`
+    fn sequence(
+        &mut self,
+        wire_type: WireType,
+        mut input: &[u8],
+        offset: usize,
+        depth: usize,
+    ) -> Result<(), Error> {
+        let end = offset + input.len();
+        let mut decoder = sequence::Decoder::new(wire_type, input.len());
+
+        while !input.is_empty() {
+            let value_offset = end - input.len();
+
+            let value = match wire_type {
+                WireType::UInt64 => decoder.decode_uint64(&mut input).map(Value::UInt64)?,
+                WireType::SInt64 => decoder.decode_sint64(&mut input).map(Value::SInt64)?,
+                WireType::Bytes => decoder.decode_bytes(&mut input).map(Value::Bytes)?,
+                WireType::String => decoder.decode_string(&mut input).map(Value::String)?,
+                WireType::Message => decoder.decode_message(&mut input).map(Value::Message)?,
+                WireType::Sequence => nested_sequence(&mut decoder, &mut input)?,
+                _ => {
+                    return Err(Error::Decode {
+                        element: Element::Value,
+                        wire_type,
+                    })
+                }
+            };
+
+            self.offset(value_offset)?;
+            self.header(&[])?;
+            self.indent(depth)?;
+            write!(self, "- ")?;
+            self.value(&value, end - input.len(), depth)?;
+        }
+
+        Ok(())
+    }
+
+    /// Describe a value whose encoding ends at `end`, followed by a newline
+    fn value(&mut self, value: &Value<'_>, end: usize, depth: usize) -> Result<(), Error> {
+        write!(self, "{}", value.wire_type())?;
+
+        match *value {
+            Value::Bool(_) => writeln!(self),
+            Value::UInt64(n) => writeln!(self, " = {}", n),
+            Value::SInt64(n) => writeln!(self, " = {}", n),
+            Value::Bytes(bytes) => {
+                write!(self, " (len {}) = ", bytes.len())?;
+                self.hex(&bytes[..bytes.len().min(PREVIEW_BYTES)])?;
+
+                if bytes.len() > PREVIEW_BYTES {
+                    write!(self, "...")?;
+                }
+
+                writeln!(self)
+            }
+            Value::String(string) => {
+                write!(self, " (len {}) = ", string.len())?;
+
+                match string.char_indices().nth(PREVIEW_CHARS) {
+                    Some((index, _)) => writeln!(self, "{:?}...", &string[..index]),
+                    None => writeln!(self, "{:?}", string),
+                }
+            }
+            Value::Message(bytes) => {
+                writeln!(self, " (len {})", bytes.len())?;
+                let depth = self.nest(depth)?;
+                self.message(bytes, end - bytes.len(), depth)
+            }
+            Value::Sequence { wire_type, bytes } => {
+                writeln!(self, "<{}> (len {})", wire_type, bytes.len())?;
+                let depth = self.nest(depth)?;
+                self.sequence(wire_type, bytes, end - bytes.len(), depth)
+            }
+        }
+    }
+
+    /// Get the depth of values nested one level below `depth`, returning an
+    /// error if that exceeds the maximum depth
+    fn nest(&self, depth: usize) -> Result<usize, Error> {
+        if depth < self.limits.max_depth {
+            Ok(depth + 1)
+        } else {
+            Err(Error::Length)
+        }
+    }
+
+    /// Write the offset column
+    fn offset(&mut self, offset: usize) -> Result<(), Error> {
+        write!(self, "{:>6}  ", offset)
+    }
+
+    /// Write the header column
+    fn header(&mut self, header: &[u8]) -> Result<(), Error> {
+        self.hex(header)?;
+        write!(self, "{:1$}  ", "", HEADER_WIDTH - header.len() * 2)
+    }
+
+    /// Indent a line for the given nesting depth
+    fn indent(&mut self, depth: usize) -> Result<(), Error> {
+        write!(self, "{:1$}", "", depth * 2)
+    }
+
+    /// Write the given bytes as hexadecimal
+    fn hex(&mut self, bytes: &[u8]) -> Result<(), Error> {
+        for byte in bytes {
+            write!(self, "{:02x}", byte)?;
+        }
+
+        Ok(())
+    }
+
+    /// Write formatted output, allowing use of the `write!` macro
+    fn write_fmt(&mut self, args: fmt::Arguments<'_>) -> Result<(), Error> {
+        self.out.write_fmt(args).map_err(|_| Error::Failed)
+    }
+}
+
+#[cfg(all(test, feature = "alloc"))]
+mod tests {
+    use super::{explain, explain_with_limits};
+    use crate::{decoder::Limits, error::Error};
+    use alloc::{string::String, vec::Vec};
+
+    #[test]
+    fn explain_nested_message() {
+        let input = [45, 5, 69, 7, 73, 11, 98, 121, 116, 101, 115];
+        let mut output = String::new();
+        explain(&input, &mut output).unwrap();
+
+        assert_eq!(
+            output,
+            "     0  2d                  [1] message (len 2)\n\
+             \x20    2  45                    [2] uint64 = 3\n\
+             \x20    4  49                  [2] bytes (len 5) = 6279746573\n"
+        );
+    }
+
+    #[test]
+    fn explain_over_limit() {
+        let input = [45, 5, 69, 7, 73, 11, 98, 121, 116, 101, 115];
+        let mut output = String::new();
+        let limits = Limits {
+            max_bytes_len: 4,
+            ..Limits::default()
+        };
+
+        let error = explain_with_limits(&input, &mut output, limits)
+            .err()
+            .unwrap();
+
+        // Fields preceding the one over the limit are still described
+        assert_eq!(error, Error::Length);
+        assert_eq!(output.lines().count(), 2);
+    }
+
+    #[test]
+    fn explain_deeply_nested_message() {
+        // 10,000 levels of messages nested in field 1, built from the inside out
+        let mut input = Vec::new();
+
+        for _ in 0..10_000 {
+            let length = vint64::encode(input.len() as u64);
+            input.extend(length.as_ref().iter().rev());
+            input.push(45);
+        }
+
+        input.reverse();
+
+        let mut output = String::new();
+        let error = explain(&input, &mut output).err().unwrap();
+        assert_eq!(error, Error::Length);
+        assert_eq!(output.lines().count(), Limits::default().max_depth + 1);
+    }
+
+    #[test]
+    fn explain_over_max_depth() {
+        let input = [45, 5, 69, 7];
+        let mut output = String::new();
+        let limits = Limits {
+            max_depth: 0,
+            ..Limits::default()
+        };
+
+        let error = explain_with_limits(&input, &mut output, limits)
+            .err()
+            .unwrap();
+
+        assert_eq!(error, Error::Length);
+        assert_eq!(output.lines().count(), 1);
+    }
+
+    #[test]
+    fn explain_sequence() {
+        let input = [175, 101, 3, 5, 7];
+        let mut output = String::new();
+        explain(&input, &mut output).unwrap();
+
+        assert_eq!(
+            output,
+            "     0  af                  [5] sequence<uint64> (len 3)\n\
+             \x20    2                        - uint64 = 1\n\
+             \x20    3                        - uint64 = 2\n\
+             \x20    4                        - uint64 = 3\n"
+        );
+    }
+
+    #[test]
+    fn explain_nested_sequence() {
+        let input = [47, 79, 37, 3];
+        let mut output = String::new();
+        explain(&input, &mut output).unwrap();
+
+        assert_eq!(
+            output,
+            "     0  2f                  [1] sequence<sequence> (len 2)\n\
+             \x20    2                        - sequence<uint64> (len 1)\n\
+             \x20    3                          - uint64 = 1\n"
+        );
+    }
+}
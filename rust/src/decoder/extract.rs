@@ -0,0 +1,135 @@
+//! Extraction of individual fields from encoded messages
+
+use super::{Decoder, Limits, Value};
+use crate::{
+    error::Error,
+    field::{Tag, WireType},
+};
+
+/// Extract the value of the field at the given path of tags from an encoded
+/// message, without decoding any more of the message than necessary.
+///
+/// Fields preceding the one being extracted are skipped over using their
+/// length delimiters, without validating their contents.
+///
+/// Every tag in the path except the last must identify a field containing a
+/// nested message. Returns `None` if any field along the path is absent.
+/// An empty path refers to the message itself.
+///
+/// Fields after the one being extracted are not examined, so malformed data
+/// following it will not be detected.
+pub fn extract<'a>(message: &'a [u8], path: &[Tag]) -> Result<Option<Value<'a>>, Error> {
+    extract_with_limits(message, path, Limits::default())
+}
+
+/// Extract the value of a field like [`extract`], enforcing the given
+/// [`Limits`] on the message and on any nested messages along the path
+pub fn extract_with_limits<'a>(
+    mut message: &'a [u8],
+    path: &[Tag],
+    limits: Limits,
+) -> Result<Option<Value<'a>>, Error> {
+    let (&tag, rest) = match path.split_first() {
+        Some(split) => split,
+        None => return Ok(Some(Value::Message(message))),
+    };
+
+    let mut decoder = Decoder::with_limits(limits);
+
+    while !message.is_empty() {
+        let header = decoder.decode_header(&mut message)?;
+
+        // Fields are ordered by tag, so the one we want can't come later
+        if header.tag > tag {
+            break;
+        }
+
+        if header.tag < tag {
+            decoder.skip_value(header.wire_type, &mut message)?;
+            continue;
+        }
+
+        let value = decoder.decode_value(header.wire_type, &mut message)?;
+
+        if rest.is_empty() {
+            return Ok(Some(value));
+        }
+
+        return match value {
+            Value::Message(nested) => extract_with_limits(nested, rest, limits),
+            _ => Err(Error::WireType {
+                wanted: Some(WireType::Message),
+            }),
+        };
+    }
+
+    Ok(None)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::{extract, extract_with_limits};
+    use crate::{
+        decoder::{Limits, Value},
+        error::Error,
+        field::WireType,
+    };
+
+    const EXAMPLE_MESSAGE: &[u8] = &[45, 5, 69, 7, 73, 11, 98, 121, 116, 101, 115];
+
+    #[test]
+    fn extract_field() {
+        let value = extract(EXAMPLE_MESSAGE, &[2]).unwrap();
+        assert_eq!(value, Some(Value::Bytes(b"bytes")));
+    }
+
+    #[test]
+    fn extract_nested_field() {
+        let value = extract(EXAMPLE_MESSAGE, &[1, 2]).unwrap();
+        assert_eq!(value, Some(Value::UInt64(3)));
+    }
+
+    #[test]
+    fn extract_after_invalid_string() {
+        let input = [43, 3, 0xff, 69, 7];
+        assert_eq!(extract(&input, &[2]).unwrap(), Some(Value::UInt64(3)));
+    }
+
+    #[test]
+    fn extract_over_limit() {
+        let limits = Limits {
+            max_tag: 1,
+            ..Limits::default()
+        };
+
+        let error = extract_with_limits(EXAMPLE_MESSAGE, &[1, 2], limits)
+            .err()
+            .unwrap();
+
+        assert_eq!(
+            error,
+            Error::FieldHeader {
+                tag: Some(2),
+                wire_type: Some(WireType::UInt64)
+            }
+        );
+    }
+
+    #[test]
+    fn extract_missing_field() {
+        assert_eq!(extract(EXAMPLE_MESSAGE, &[0]).unwrap(), None);
+        assert_eq!(extract(EXAMPLE_MESSAGE, &[3]).unwrap(), None);
+        assert_eq!(extract(EXAMPLE_MESSAGE, &[1, 3]).unwrap(), None);
+    }
+
+    #[test]
+    fn extract_through_non_message() {
+        let error = extract(EXAMPLE_MESSAGE, &[2, 1]).err().unwrap();
+        assert_eq!(
+            error,
+            Error::WireType {
+                wanted: Some(WireType::Message)
+            }
+        );
+    }
+}
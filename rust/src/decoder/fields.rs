@@ -0,0 +1,125 @@
+//! Iteration over the fields of encoded messages
+
+use super::{Decoder, Limits, Value};
+use crate::{error::Error, field::Header};
+
+/// Iterator over the fields of an encoded message.
+///
+/// Fields are yielded in wire order, which Veriform requires to be
+/// ascending by tag. Iteration stops after the first error.
+#[derive(Debug)]
+pub struct Fields<'a> {
+    /// Decoder for the message
+    decoder: Decoder,
+
+    /// Remaining input in the message
+    input: &'a [u8],
+}
+
+impl<'a> Fields<'a> {
+    /// Iterate over the fields of the given encoded message
+    pub fn new(message: &'a [u8]) -> Self {
+        Self::with_limits(message, Limits::default())
+    }
+
+    /// Iterate over the fields of the given encoded message, enforcing the
+    /// given [`Limits`] on it
+    pub fn with_limits(message: &'a [u8], limits: Limits) -> Self {
+        Self {
+            decoder: Decoder::with_limits(limits),
+            input: message,
+        }
+    }
+}
+
+impl<'a> Iterator for Fields<'a> {
+    type Item = Result<(Header, Value<'a>), Error>;
+
+    fn next(&mut self) -> Option<Self::Item> {
+        if self.input.is_empty() {
+            return None;
+        }
+
+        let result = match self.decoder.decode_header(&mut self.input) {
+            Ok(header) => self
+                .decoder
+                .decode_value(header.wire_type, &mut self.input)
+                .map(|value| (header, value)),
+            Err(e) => Err(e),
+        };
+
+        if result.is_err() {
+            self.input = &[];
+        }
+
+        Some(result)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::Fields;
+    use crate::{
+        decoder::{Limits, Value},
+        error::Error,
+        field::WireType,
+    };
+
+    #[test]
+    fn iterate_fields() {
+        let input = [45, 5, 69, 7, 73, 11, 98, 121, 116, 101, 115];
+        let mut fields = Fields::new(&input);
+
+        let (header, value) = fields.next().unwrap().unwrap();
+        assert_eq!(header.tag, 1);
+        assert_eq!(header.wire_type, WireType::Message);
+        assert_eq!(value, Value::Message(&[69, 7]));
+
+        let (header, value) = fields.next().unwrap().unwrap();
+        assert_eq!(header.tag, 2);
+        assert_eq!(header.wire_type, WireType::Bytes);
+        assert_eq!(value, Value::Bytes(b"bytes"));
+
+        assert!(fields.next().is_none());
+    }
+
+    #[test]
+    fn stop_after_error() {
+        let input = [206, 10, 167, 138, 10, 85];
+        let mut fields = Fields::new(&input);
+
+        assert_eq!(fields.next().unwrap().unwrap().1, Value::SInt64(-42));
+        assert_eq!(
+            fields.next().unwrap().err().unwrap(),
+            Error::Order { tag: 42 }
+        );
+        assert!(fields.next().is_none());
+    }
+
+    #[test]
+    fn iterate_with_limits() {
+        let input = [45, 5, 69, 7, 73, 11, 98, 121, 116, 101, 115];
+        let limits = Limits {
+            max_bytes_len: 4,
+            ..Limits::default()
+        };
+
+        let mut fields = Fields::with_limits(&input, limits);
+        assert!(fields.next().unwrap().is_ok());
+        assert_eq!(fields.next().unwrap().err().unwrap(), Error::Length);
+        assert!(fields.next().is_none());
+    }
+
+    #[test]
+    fn bytes_sequence_over_limit() {
+        let input = [47, 201, 11, 1, 2, 3, 4, 5];
+        let limits = Limits {
+            max_bytes_len: 2,
+            ..Limits::default()
+        };
+
+        let mut fields = Fields::with_limits(&input, limits);
+        assert_eq!(fields.next().unwrap().err().unwrap(), Error::Length);
+        assert!(fields.next().is_none());
+    }
+}
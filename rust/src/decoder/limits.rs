@@ -0,0 +1,46 @@
+//! Limits on the messages accepted by the decoder
+
+use crate::field::{Tag, MAX_TAG};
+
+/// Limits enforced by the decoder on incoming messages.
+///
+/// The default limits accept anything which is otherwise well-formed, apart
+/// from nesting deeper than [`Limits::max_depth`] allows.
+/// Limits are set per decoder (see
+/// [`Decoder::with_limits`](super::Decoder::with_limits) and
+/// [`Fields::with_limits`](super::Fields::with_limits), as well as
+/// [`extract_with_limits`](super::extract_with_limits),
+/// [`explain_with_limits`](super::explain_with_limits) and
+/// [`project_with_limits`](crate::encoder::project_with_limits)), so e.g.
+/// untrusted input can be held to stricter limits than trusted input in the
+/// same program.
+#[derive(Copy, Clone, Debug, Eq, PartialEq)]
+pub struct Limits {
+    /// Maximum length of the value of a single `bytes` field.
+    ///
+    /// A `sequence` of `bytes` is held to the same limit as a whole: its
+    /// total length, including each element's length prefix, can't exceed
+    /// it, so no element can either. The same goes for a `sequence` of
+    /// nested sequences, whatever they contain, since they may hold `bytes`.
+    pub max_bytes_len: usize,
+
+    /// Maximum tag (i.e. ID) of a field
+    pub max_tag: Tag,
+
+    /// Maximum number of levels of messages and sequences nested inside a
+    /// message, enforced by functions which descend into nested values on
+    /// their own (e.g. `explain`).
+    ///
+    /// Defaults to 64, so deeply nested input can't exhaust the stack.
+    pub max_depth: usize,
+}
+
+impl Default for Limits {
+    fn default() -> Self {
+        Self {
+            max_bytes_len: core::usize::MAX,
+            max_tag: MAX_TAG,
+            max_depth: 64,
+        }
+    }
+}
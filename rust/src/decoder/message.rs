@@ -4,7 +4,7 @@ mod body;
 mod header;
 mod value;
 
-use super::{Decodable, Event};
+use super::{Decodable, Event, Limits, Value};
 use crate::{
     error::Error,
     field::{Header, Tag, WireType},
@@ -13,6 +13,9 @@ use crate::{
 
 /// Veriform decoder: streaming zero-copy pull parser which emits events based
 /// on incoming data.
+///
+/// Decoders are fail-stop: after any error, all subsequent calls return
+/// [`Error::Failed`] and a new decoder is needed to decode another message.
 #[derive(Debug)]
 pub struct Decoder {
     /// Last field tag that was decoded (to ensure monotonicity)
@@ -23,29 +26,49 @@ pub struct Decoder {
 
     /// Current state of the decoder (or `None` if an error occurred)
     state: Option<State>,
+
+    /// Limits to enforce on the message being decoded
+    limits: Limits,
 }
 
 impl Default for Decoder {
     fn default() -> Self {
-        Self {
-            state: Some(State::default()),
-            last_tag: None,
-            position: 0,
-        }
+        Self::with_limits(Limits::default())
     }
 }
 
 impl Decodable for Decoder {
     fn decode<'a>(&mut self, input: &mut &'a [u8]) -> Result<Option<Event<'a>>, Error> {
         if let Some(state) = self.state.take() {
+            let orig_input_len = input.len();
             let (new_state, event) = state.decode(input, self.last_tag)?;
 
-            if let Some(Event::FieldHeader(header)) = &event {
-                self.last_tag = Some(header.tag);
+            match &event {
+                Some(Event::FieldHeader(header)) if header.tag > self.limits.max_tag => {
+                    return Err(Error::FieldHeader {
+                        tag: Some(header.tag),
+                        wire_type: Some(header.wire_type),
+                    })
+                }
+                Some(Event::FieldHeader(header)) => self.last_tag = Some(header.tag),
+                Some(Event::LengthDelimiter {
+                    wire_type: WireType::Bytes,
+                    length,
+                })
+                | Some(Event::SequenceHeader {
+                    wire_type: WireType::Bytes,
+                    length,
+                })
+                | Some(Event::SequenceHeader {
+                    wire_type: WireType::Sequence,
+                    length,
+                }) if *length > self.limits.max_bytes_len => return Err(Error::Length),
+                _ => (),
             }
 
             self.state = Some(new_state);
-            self.position = self.position.checked_add(input.len()).unwrap();
+            let consumed = orig_input_len.checked_sub(input.len()).unwrap();
+            self.position = self.position.checked_add(consumed).unwrap();
             Ok(event)
         } else {
             Err(Error::Failed)
@@ -58,28 +81,14 @@ impl Decodable for Decoder {
         input: &mut &'a [u8],
     ) -> Result<&'a [u8], Error> {
         let length = self.decode_length_delimiter(input, expected_type)?;
+        let bytes = self.decode_body(input, expected_type)?;
+        debug_assert_eq!(length, bytes.len());
+        Ok(bytes)
+    }
 
-        match self.decode(input)? {
-            Some(Event::ValueChunk {
-                wire_type,
-                bytes,
-                remaining,
-            }) if wire_type == expected_type => {
-                if remaining == 0 {
-                    debug_assert_eq!(length, bytes.len());
-                    Ok(bytes)
-                } else {
-                    Err(Error::Truncated {
-                        remaining,
-                        wire_type,
-                    })
-                }
-            }
-            _ => Err(Error::Decode {
-                element: Element::Value,
-                wire_type: expected_type,
-            }),
-        }
+    fn fail(&mut self, error: Error) -> Error {
+        self.state = None;
+        error
     }
 }
 
@@ -89,6 +98,21 @@ impl Decoder {
         Self::default()
     }
 
+    /// Create a new decoder which enforces the given [`Limits`]
+    pub fn with_limits(limits: Limits) -> Self {
+        Self {
+            state: Some(State::default()),
+            last_tag: None,
+            position: 0,
+            limits,
+        }
+    }
+
+    /// Get the [`Limits`] enforced by this decoder
+    pub fn limits(&self) -> Limits {
+        self.limits
+    }
+
     /// Get the tag (i.e. ID) of the last decoded field header
     pub fn last_tag(&self) -> Option<Tag> {
         self.last_tag
@@ -100,14 +124,31 @@ impl Decoder {
         self.position
     }
 
+    /// Finish decoding a message which is expected to span all of the given
+    /// input, i.e. whatever remains of it after decoding the last field.
+    ///
+    /// Returns an error if any input is left over or decoding stopped
+    /// partway through a field, so data can't be smuggled past a decoder
+    /// that only reads the fields it expects.
+    pub fn finish(self, input: &[u8]) -> Result<(), Error> {
+        if !input.is_empty() {
+            return Err(Error::TrailingData);
+        }
+
+        match self.state {
+            Some(state) => state.finish(),
+            None => Err(Error::Failed),
+        }
+    }
+
     /// Decode an expected field header, returning an error for anything else
     pub fn decode_header(&mut self, input: &mut &[u8]) -> Result<Header, Error> {
         match self.decode(input)? {
             Some(Event::FieldHeader(header)) => Ok(header),
-            _ => Err(Error::FieldHeader {
+            _ => Err(self.fail(Error::FieldHeader {
                 tag: None,
                 wire_type: None,
-            }),
+            })),
         }
     }
 
@@ -124,26 +165,82 @@ impl Decoder {
                 tag: Some(tag),
                 wire_type: Some(wire_type),
             },
-            _ => unreachable!("unexpected decode_header error: {:?}", e),
+            e => e,
         })?;
 
         // TODO(tarcieri): actually skip unknown fields
         if header.tag != tag {
-            return Err(Error::Decode {
+            return Err(self.fail(Error::Decode {
                 element: Element::Tag,
                 wire_type,
-            });
+            }));
         }
 
         if header.wire_type != wire_type {
-            return Err(Error::WireType {
+            return Err(self.fail(Error::WireType {
                 wanted: Some(wire_type),
-            });
+            }));
         }
 
         Ok(())
     }
 
+    /// Decode the value of a field with the given wire type, i.e. the wire
+    /// type of the field header which was just decoded
+    pub fn decode_value<'a>(
+        &mut self,
+        wire_type: WireType,
+        input: &mut &'a [u8],
+    ) -> Result<Value<'a>, Error> {
+        match wire_type {
+            WireType::False | WireType::True => match self.decode(input)? {
+                Some(Event::Bool(value)) => Ok(Value::Bool(value)),
+                _ => Err(self.fail(Error::Decode {
+                    element: Element::Value,
+                    wire_type,
+                })),
+            },
+            WireType::UInt64 => self.decode_uint64(input).map(Value::UInt64),
+            WireType::SInt64 => self.decode_sint64(input).map(Value::SInt64),
+            WireType::Bytes => self.decode_bytes(input).map(Value::Bytes),
+            WireType::String => self.decode_string(input).map(Value::String),
+            WireType::Message => self.decode_message(input).map(Value::Message),
+            WireType::Sequence => {
+                let (wire_type, length) = match self.decode(input)? {
+                    Some(Event::SequenceHeader { wire_type, length }) => (wire_type, length),
+                    _ => {
+                        return Err(self.fail(Error::Decode {
+                            element: Element::SequenceHeader,
+                            wire_type: WireType::Sequence,
+                        }))
+                    }
+                };
+
+                let bytes = self.decode_body(input, WireType::Sequence)?;
+                debug_assert_eq!(length, bytes.len());
+                Ok(Value::Sequence { wire_type, bytes })
+            }
+        }
+    }
+
+    /// Skip over the value of a field with the given wire type, i.e. the wire
+    /// type of the field header which was just decoded.
+    ///
+    /// Unlike [`Decoder::decode_value`], the contents of length-delimited
+    /// values are skipped using their length without being examined, so
+    /// e.g. strings aren't checked to be valid UTF-8.
+    pub fn skip_value(&mut self, wire_type: WireType, input: &mut &[u8]) -> Result<(), Error> {
+        match wire_type {
+            WireType::Bytes | WireType::String | WireType::Message => {
+                let length = self.decode_length_delimiter(input, wire_type)?;
+                let bytes = self.decode_body(input, wire_type)?;
+                debug_assert_eq!(length, bytes.len());
+                Ok(())
+            }
+            _ => self.decode_value(wire_type, input).map(|_| ()),
+        }
+    }
+
     /// Decode a length delimiter, expecting the given wire type
     fn decode_length_delimiter(
         &mut self,
@@ -160,10 +257,39 @@ impl Decoder {
             Some(Event::LengthDelimiter { wire_type, length }) if wire_type == expected_type => {
                 Ok(length)
             }
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::LengthDelimiter,
                 wire_type: expected_type,
-            }),
+            })),
+        }
+    }
+
+    /// Decode the body of a dynamically sized value, expecting the given
+    /// wire type and returning an error if it's truncated
+    fn decode_body<'a>(
+        &mut self,
+        input: &mut &'a [u8],
+        expected_type: WireType,
+    ) -> Result<&'a [u8], Error> {
+        match self.decode(input)? {
+            Some(Event::ValueChunk {
+                wire_type,
+                bytes,
+                remaining,
+            }) if wire_type == expected_type => {
+                if remaining == 0 {
+                    Ok(bytes)
+                } else {
+                    Err(self.fail(Error::Truncated {
+                        remaining,
+                        wire_type,
+                    }))
+                }
+            }
+            _ => Err(self.fail(Error::Decode {
+                element: Element::Value,
+                wire_type: expected_type,
+            })),
         }
     }
 }
@@ -196,24 +322,25 @@ impl State {
         }
     }
 
+    /// Ensure decoding ended at a field boundary
+    pub(super) fn finish(self) -> Result<(), Error> {
+        match self {
+            State::Header(header) => header.finish(),
+            State::Value(value) => value.finish(),
+            State::Body(body) => body.finish(),
+        }
+    }
+
     /// Get the new state to transition to based on a given event
     pub(super) fn transition(event: &Event<'_>) -> Self {
         match event {
             Event::FieldHeader(header) => value::Decoder::new(header.wire_type).into(),
             Event::Bool(_) | Event::UInt64(_) | Event::SInt64(_) => State::default(),
             Event::LengthDelimiter { wire_type, length } => {
-                if *length > 0 {
-                    body::Decoder::new(*wire_type, *length).into()
-                } else {
-                    State::default()
-                }
+                body::Decoder::new(*wire_type, *length).into()
             }
             Event::SequenceHeader { length, .. } => {
-                if *length > 0 {
-                    body::Decoder::new(WireType::Sequence, *length).into()
-                } else {
-                    State::default()
-                }
+                body::Decoder::new(WireType::Sequence, *length).into()
             }
             Event::ValueChunk {
                 wire_type,
@@ -244,8 +371,8 @@ impl From<value::Decoder> for State {
 
 #[cfg(test)]
 mod tests {
-    use super::{Decodable, Decoder, WireType};
-    use crate::error::Error;
+    use super::{Decodable, Decoder, Limits, Value, WireType};
+    use crate::{error::Error, message::Element};
 
     #[test]
     fn decode_false() {
@@ -269,6 +396,23 @@ mod tests {
         assert_eq!(header.wire_type, WireType::True);
     }
 
+    #[test]
+    fn decode_bool_value() {
+        let input = [130, 10, 206, 10, 167];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        let value = decoder
+            .decode_value(header.wire_type, &mut input_ref)
+            .unwrap();
+        assert_eq!(value, Value::Bool(false));
+
+        // Booleans have no encoded value, so the next field follows the header
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(header.tag, 43);
+    }
+
     #[test]
     fn decode_uint64() {
         let input = [138, 10, 85];
@@ -314,6 +458,197 @@ mod tests {
         assert!(input_ref.is_empty());
     }
 
+    #[test]
+    fn decode_empty_bytes() {
+        let input = [73, 1, 139, 7, 98, 97, 122];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(decoder.decode_bytes(&mut input_ref).unwrap(), &[]);
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(header.tag, 4);
+        assert_eq!(decoder.decode_string(&mut input_ref).unwrap(), "baz");
+    }
+
+    #[test]
+    fn position_counts_consumed_bytes() {
+        let input = [138, 10, 85, 206, 10, 167];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(decoder.position(), 2);
+
+        decoder.decode_uint64(&mut input_ref).unwrap();
+        assert_eq!(decoder.position(), 3);
+    }
+
+    #[test]
+    fn decode_after_error() {
+        let input = [206, 10, 167, 138, 10];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        decoder.decode_sint64(&mut input_ref).unwrap();
+        assert_eq!(
+            decoder.decode_header(&mut input_ref).err().unwrap(),
+            Error::Order { tag: 42 }
+        );
+
+        // Decoders are fail-stop: once an error occurs, they can't be reused
+        let mut input_ref = &input[..];
+        assert_eq!(decoder.decode(&mut input_ref).err().unwrap(), Error::Failed);
+    }
+
+    #[test]
+    fn decode_after_invalid_string() {
+        let input = [43, 3, 0xff, 69, 7];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(
+            decoder.decode_string(&mut input_ref).err().unwrap(),
+            Error::Utf8 { valid_up_to: 0 }
+        );
+        assert_eq!(
+            decoder.decode_header(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_after_unexpected_header() {
+        let input = [138, 10, 85];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        assert_eq!(
+            decoder
+                .decode_expected_header(&mut input_ref, 41, WireType::UInt64)
+                .err()
+                .unwrap(),
+            Error::Decode {
+                element: Element::Tag,
+                wire_type: WireType::UInt64
+            }
+        );
+        assert_eq!(
+            decoder.decode_uint64(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_after_unexpected_event() {
+        let input = [138, 10, 85, 206, 10, 167];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(
+            decoder.decode_bytes(&mut input_ref).err().unwrap(),
+            Error::Decode {
+                element: Element::LengthDelimiter,
+                wire_type: WireType::Bytes
+            }
+        );
+        assert_eq!(
+            decoder.decode_header(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn finish_at_end_of_message() {
+        let input = [138, 10, 85];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        decoder.decode_uint64(&mut input_ref).unwrap();
+        decoder.finish(input_ref).unwrap();
+    }
+
+    #[test]
+    fn finish_after_zero_length_delimiter() {
+        let input = [73, 1];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        decoder.decode(&mut input_ref).unwrap();
+        assert!(input_ref.is_empty());
+        decoder.finish(input_ref).unwrap();
+    }
+
+    #[test]
+    fn finish_with_trailing_data() {
+        let input = [138, 10, 85, 206, 10, 167];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        decoder.decode_uint64(&mut input_ref).unwrap();
+        assert_eq!(
+            decoder.finish(input_ref).err().unwrap(),
+            Error::TrailingData
+        );
+    }
+
+    #[test]
+    fn finish_mid_field() {
+        let input = [73, 11, 98, 121];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        decoder.decode(&mut input_ref).unwrap();
+        decoder.decode(&mut input_ref).unwrap();
+
+        assert_eq!(
+            decoder.finish(input_ref).err().unwrap(),
+            Error::Truncated {
+                remaining: 3,
+                wire_type: WireType::Bytes
+            }
+        );
+    }
+
+    #[test]
+    fn decode_bytes_over_limit() {
+        let input = [73, 11, 98, 121, 116, 101, 115];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::with_limits(Limits {
+            max_bytes_len: 4,
+            ..Limits::default()
+        });
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        let error = decoder.decode_bytes(&mut input_ref).err().unwrap();
+        assert_eq!(error, Error::Length);
+    }
+
+    #[test]
+    fn decode_nested_bytes_sequence_over_limit() {
+        let input = [47, 239, 201, 11, 1, 2, 3, 4, 5];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::with_limits(Limits {
+            max_bytes_len: 2,
+            ..Limits::default()
+        });
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        let error = decoder
+            .decode_value(header.wire_type, &mut input_ref)
+            .err()
+            .unwrap();
+        assert_eq!(error, Error::Length);
+    }
+
     #[test]
     fn decode_string() {
         let input = [139, 7, 98, 97, 122];
@@ -344,6 +679,66 @@ mod tests {
         assert!(input_ref.is_empty());
     }
 
+    #[test]
+    fn decode_sequence_value() {
+        let input = [175, 101, 3, 5, 7];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(header.tag, 5);
+        assert_eq!(header.wire_type, WireType::Sequence);
+
+        let value = decoder
+            .decode_value(header.wire_type, &mut input_ref)
+            .unwrap();
+
+        assert_eq!(
+            value,
+            Value::Sequence {
+                wire_type: WireType::UInt64,
+                bytes: &[3, 5, 7]
+            }
+        );
+        assert!(input_ref.is_empty());
+    }
+
+    #[test]
+    fn decode_expected_header_duplicate() {
+        let input = [0x05, 0x03, 0x05, 0x03];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder
+            .decode_expected_header(&mut input_ref, 0, WireType::UInt64)
+            .unwrap();
+        decoder.decode_uint64(&mut input_ref).unwrap();
+
+        assert_eq!(
+            decoder
+                .decode_expected_header(&mut input_ref, 1, WireType::UInt64)
+                .err()
+                .unwrap(),
+            Error::Duplicate { tag: 0 }
+        );
+    }
+
+    #[test]
+    fn skip_invalid_string() {
+        let input = [43, 3, 0xff, 69, 7];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        decoder
+            .skip_value(header.wire_type, &mut input_ref)
+            .unwrap();
+
+        let header = decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(header.tag, 2);
+        assert_eq!(decoder.decode_uint64(&mut input_ref).unwrap(), 3);
+    }
+
     #[test]
     fn decode_multiple() {
         let input = [138, 10, 85, 206, 10, 167];
@@ -366,6 +761,25 @@ mod tests {
         assert!(input_ref.is_empty());
     }
 
+    #[test]
+    fn decode_tag_over_limit() {
+        let input = [138, 10, 85];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::with_limits(Limits {
+            max_tag: 41,
+            ..Limits::default()
+        });
+
+        let error = decoder.decode(&mut input_ref).err().unwrap();
+        assert_eq!(
+            error,
+            Error::FieldHeader {
+                tag: Some(42),
+                wire_type: Some(WireType::UInt64)
+            }
+        );
+    }
+
     #[test]
     fn decode_partial_field_header() {
         let input = [138, 10, 85];
@@ -396,4 +810,17 @@ mod tests {
         let error = decoder.decode(&mut input_ref).err().unwrap();
         assert_eq!(error, Error::Order { tag: 42 })
     }
+
+    #[test]
+    fn decode_duplicate() {
+        let input = [138, 10, 85, 138, 10, 85];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new();
+
+        decoder.decode_header(&mut input_ref).unwrap();
+        assert_eq!(decoder.decode_uint64(&mut input_ref).unwrap(), 42);
+
+        let error = decoder.decode(&mut input_ref).err().unwrap();
+        assert_eq!(error, Error::Duplicate { tag: 42 })
+    }
 }
@@ -32,7 +32,8 @@ impl Decoder {
     /// Process the given input data, advancing the slice for the amount of
     /// data processed, and returning the new state.
     pub fn decode<'a>(self, input: &mut &'a [u8]) -> Result<(State, Option<Event<'a>>), Error> {
-        if input.is_empty() {
+        // Empty values still produce a (zero-length) chunk
+        if input.is_empty() && self.remaining > 0 {
             return Ok((self.into(), None));
         }
 
@@ -55,6 +56,19 @@ impl Decoder {
         let new_state = State::transition(&event);
         Ok((new_state, Some(event)))
     }
+
+    /// Report the rest of this field body as missing, if there is any
+    pub fn finish(self) -> Result<(), Error> {
+        // Zero-length values are complete once their length is known
+        if self.remaining == 0 {
+            return Ok(());
+        }
+
+        Err(Error::Truncated {
+            remaining: self.remaining,
+            wire_type: self.wire_type,
+        })
+    }
 }
 
 impl From<Decoder> for State {
@@ -24,7 +24,11 @@ impl Decoder {
 
             // Ensure field ordering is monotonically increasing
             if let Some(tag) = last_tag {
-                if header.tag <= tag {
+                if header.tag == tag {
+                    return Err(Error::Duplicate { tag });
+                }
+
+                if header.tag < tag {
                     return Err(Error::Order { tag: header.tag });
                 }
             }
@@ -36,4 +40,16 @@ impl Decoder {
             Ok((State::Header(self), None))
         }
     }
+
+    /// Ensure a field header wasn't left partially decoded
+    pub fn finish(self) -> Result<(), Error> {
+        if self.0.is_partial() {
+            Err(Error::FieldHeader {
+                tag: None,
+                wire_type: None,
+            })
+        } else {
+            Ok(())
+        }
+    }
 }
@@ -8,6 +8,7 @@ use crate::{
     },
     error::Error,
     field::WireType,
+    message::Element,
 };
 
 /// Decoder for field values
@@ -32,34 +33,57 @@ impl Decoder {
     /// Process the given input data, advancing the slice for the amount of
     /// data processed, and returning the new state.
     pub fn decode<'a>(mut self, input: &mut &'a [u8]) -> Result<(State, Option<Event<'a>>), Error> {
-        if let Some(value) = self.decoder.decode(input)? {
-            let event = match self.wire_type {
-                WireType::False => Event::Bool(false),
-                WireType::True => Event::Bool(true),
-                WireType::UInt64 => Event::UInt64(value),
-                WireType::SInt64 => Event::SInt64(zigzag::decode(value)),
-                WireType::Sequence => Event::SequenceHeader {
-                    wire_type: WireType::from_unmasked(value),
-                    length: (value >> 4) as usize,
-                },
-                wire_type => {
-                    debug_assert!(
-                        wire_type.is_dynamically_sized(),
-                        "not a dynamically sized wire type: {:?}",
-                        wire_type
-                    );
+        let event = match self.wire_type {
+            // Booleans are encoded entirely by their wire type and have no value
+            WireType::False => Event::Bool(false),
+            WireType::True => Event::Bool(true),
+            _ => match self.decoder.decode(input)? {
+                Some(value) => self.value_event(value)?,
+                None => return Ok((State::Value(self), None)),
+            },
+        };
 
-                    Event::LengthDelimiter {
-                        wire_type,
-                        length: value as usize,
-                    }
-                }
-            };
+        let new_state = State::transition(&event);
+        Ok((new_state, Some(event)))
+    }
 
-            let new_state = State::transition(&event);
-            Ok((new_state, Some(event)))
-        } else {
-            Ok((State::Value(self), None))
-        }
+    /// Report the value this decoder was waiting on as missing
+    pub fn finish(self) -> Result<(), Error> {
+        let element = match self.wire_type {
+            // Booleans have no value to wait on
+            WireType::False | WireType::True => return Ok(()),
+            WireType::UInt64 | WireType::SInt64 => Element::Value,
+            WireType::Sequence => Element::SequenceHeader,
+            _ => Element::LengthDelimiter,
+        };
+
+        Err(Error::Decode {
+            element,
+            wire_type: self.wire_type,
+        })
+    }
+
+    /// Get the event for a decoded `vint64` value
+    fn value_event(&self, value: u64) -> Result<Event<'static>, Error> {
+        Ok(match self.wire_type {
+            WireType::UInt64 => Event::UInt64(value),
+            WireType::SInt64 => Event::SInt64(zigzag::decode(value)),
+            WireType::Sequence => Event::SequenceHeader {
+                wire_type: WireType::from_unmasked(value),
+                length: vint64::length(value >> 4)?,
+            },
+            wire_type => {
+                debug_assert!(
+                    wire_type.is_dynamically_sized(),
+                    "not a dynamically sized wire type: {:?}",
+                    wire_type
+                );
+
+                Event::LengthDelimiter {
+                    wire_type,
+                    length: vint64::length(value)?,
+                }
+            }
+        })
     }
 }
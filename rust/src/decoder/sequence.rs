@@ -6,7 +6,10 @@ use super::{
 };
 use crate::{error::Error, field::WireType, message::Element};
 
-/// Sequence decoder
+/// Sequence decoder.
+///
+/// Like the message decoder, this fails with [`Error::Failed`] after any
+/// error rather than attempting to resume.
 pub struct Decoder {
     /// Wire type contained in this sequence
     wire_type: WireType,
@@ -17,21 +20,47 @@ pub struct Decoder {
     /// Remaining length in the sequence body
     remaining: usize,
 
-    /// Current decoding state
-    state: State,
+    /// Current decoding state (or `None` if an error occurred)
+    state: Option<State>,
 }
 
 impl Decodable for Decoder {
     fn decode<'a>(&mut self, input: &mut &'a [u8]) -> Result<Option<Event<'a>>, Error> {
-        let orig_input_len = input.len();
-        let maybe_event = self.state.decode(self.wire_type, input)?;
-        let consumed = orig_input_len.checked_sub(input.len()).unwrap();
+        let state = self.state.as_mut().ok_or(Error::Failed)?;
+
+        // Never read past the end of the sequence
+        let body_len = if input.len() > self.remaining {
+            self.remaining
+        } else {
+            input.len()
+        };
+
+        let mut body = &input[..body_len];
+
+        let maybe_event = match state.decode(self.wire_type, &mut body) {
+            Ok(maybe_event) => maybe_event,
+            Err(e) => {
+                self.state = None;
+                return Err(e);
+            }
+        };
+
+        let consumed = body_len.checked_sub(body.len()).unwrap();
         self.remaining = self.remaining.checked_sub(consumed).unwrap();
+        *input = &input[consumed..];
 
         if let Some(event) = &maybe_event {
             self.transition(&event);
         }
 
+        // Nothing past the end of the sequence can complete a partial value
+        if self.remaining == 0 {
+            if let Err(e) = self.state.as_ref().unwrap().finish() {
+                self.state = None;
+                return Err(e);
+            }
+        }
+
         Ok(maybe_event)
     }
 
@@ -41,9 +70,9 @@ impl Decodable for Decoder {
         input: &mut &'a [u8],
     ) -> Result<&'a [u8], Error> {
         if expected_type != self.wire_type {
-            return Err(Error::WireType {
+            return Err(self.fail(Error::WireType {
                 wanted: Some(expected_type),
-            });
+            }));
         }
 
         debug_assert!(
@@ -54,10 +83,10 @@ impl Decodable for Decoder {
 
         let length = match self.decode(input)? {
             Some(Event::LengthDelimiter { length, .. }) => Ok(length),
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::LengthDelimiter,
                 wire_type: self.wire_type,
-            }),
+            })),
         }?;
 
         match self.decode(input)? {
@@ -68,18 +97,23 @@ impl Decodable for Decoder {
                     debug_assert_eq!(length, bytes.len());
                     Ok(bytes)
                 } else {
-                    Err(Error::Truncated {
+                    Err(self.fail(Error::Truncated {
                         remaining,
                         wire_type: self.wire_type,
-                    })
+                    }))
                 }
             }
-            _ => Err(Error::Decode {
+            _ => Err(self.fail(Error::Decode {
                 element: Element::Value,
                 wire_type: self.wire_type,
-            }),
+            })),
         }
     }
+
+    fn fail(&mut self, error: Error) -> Error {
+        self.state = None;
+        error
+    }
 }
 
 impl Decoder {
@@ -89,7 +123,7 @@ impl Decoder {
             wire_type,
             length,
             remaining: length,
-            state: State::default(),
+            state: Some(State::default()),
         }
     }
 
@@ -104,9 +138,92 @@ impl Decoder {
         self.remaining
     }
 
+    /// Decode a run of values from a sequence of `uint64` into `output`,
+    /// returning the number of values decoded.
+    ///
+    /// Decoding stops when `output` is full, the sequence ends, or `input`
+    /// no longer begins with a complete value. Any trailing partial value is
+    /// left in `input` to be retried once more data is available, unless
+    /// `input` already holds the rest of the sequence, in which case the
+    /// value is truncated and an error is returned.
+    pub fn decode_uint64s(
+        &mut self,
+        input: &mut &[u8],
+        output: &mut [u64],
+    ) -> Result<usize, Error> {
+        self.decode_vint64s(WireType::UInt64, input, output, ::vint64::decode_slice)
+    }
+
+    /// Decode a run of values from a sequence of `sint64` into `output`,
+    /// returning the number of values decoded.
+    ///
+    /// See [`Decoder::decode_uint64s`] for details.
+    pub fn decode_sint64s(
+        &mut self,
+        input: &mut &[u8],
+        output: &mut [i64],
+    ) -> Result<usize, Error> {
+        self.decode_vint64s(
+            WireType::SInt64,
+            input,
+            output,
+            ::vint64::signed::decode_slice,
+        )
+    }
+
+    /// Decode a run of `vint64` values in bulk, bypassing the state machine
+    fn decode_vint64s<T>(
+        &mut self,
+        expected_type: WireType,
+        input: &mut &[u8],
+        output: &mut [T],
+        decode_slice: fn(&mut &[u8], &mut [T]) -> Result<usize, ::vint64::Error>,
+    ) -> Result<usize, Error> {
+        if expected_type != self.wire_type {
+            return Err(self.fail(Error::WireType {
+                wanted: Some(expected_type),
+            }));
+        }
+
+        // Values partially consumed by `decode` can't be resumed in bulk
+        match &self.state {
+            Some(State::Value(decoder)) if !decoder.is_partial() => (),
+            Some(_) => {
+                return Err(self.fail(Error::Decode {
+                    element: Element::Value,
+                    wire_type: self.wire_type,
+                }))
+            }
+            None => return Err(Error::Failed),
+        }
+
+        let body_len = if input.len() > self.remaining {
+            self.remaining
+        } else {
+            input.len()
+        };
+
+        let mut body = &input[..body_len];
+        let count = decode_slice(&mut body, output).map_err(|_| self.fail(Error::VInt64))?;
+
+        // No more data can arrive to complete a value cut off by the end of
+        // the sequence
+        if count == 0 && !output.is_empty() && body_len == self.remaining && body_len > 0 {
+            return Err(self.fail(Error::Truncated {
+                remaining: self.remaining,
+                wire_type: self.wire_type,
+            }));
+        }
+        let consumed = body_len.checked_sub(body.len()).unwrap();
+
+        self.remaining = self.remaining.checked_sub(consumed).unwrap();
+        *input = &input[consumed..];
+        Ok(count)
+    }
+
     /// Perform a state transition after receiving an event
     fn transition<'a>(&mut self, event: &Event<'a>) {
-        self.state = match &event {
+        self.state = Some(match &event {
             Event::LengthDelimiter { wire_type, length }
             | Event::SequenceHeader { wire_type, length } => State::Body {
                 wire_type: *wire_type,
@@ -128,7 +245,7 @@ impl Decoder {
                 }
             }
             other => unreachable!("unexpected event: {:?}", other),
-        };
+        });
     }
 }
 
@@ -155,6 +272,21 @@ impl Default for State {
 }
 
 impl State {
+    /// Ensure decoding ended at a value boundary
+    pub fn finish(&self) -> Result<(), Error> {
+        match self {
+            State::Value(decoder) if decoder.is_partial() => Err(Error::VInt64),
+            State::Body {
+                wire_type,
+                remaining,
+            } if *remaining > 0 => Err(Error::Truncated {
+                remaining: *remaining,
+                wire_type: *wire_type,
+            }),
+            _ => Ok(()),
+        }
+    }
+
     /// Decode a sequence from the given input
     pub fn decode<'a>(
         &mut self,
@@ -169,7 +301,7 @@ impl State {
                         WireType::SInt64 => Event::SInt64(zigzag::decode(value)),
                         WireType::Sequence => Event::SequenceHeader {
                             wire_type: WireType::from_unmasked(value),
-                            length: (value >> 4) as usize,
+                            length: vint64::length(value >> 4)?,
                         },
                         WireType::False | WireType::True => {
                             // TODO(tarcieri): support boolean sequences?
@@ -187,7 +319,7 @@ impl State {
 
                             Event::LengthDelimiter {
                                 wire_type,
-                                length: value as usize,
+                                length: vint64::length(value)?,
                             }
                         }
                     }
@@ -199,7 +331,8 @@ impl State {
                 wire_type,
                 remaining,
             } => {
-                if input.is_empty() {
+                // Wait for more input unless this is a zero-length value
+                if input.is_empty() && *remaining > 0 {
                     return Ok(None);
                 }
 
@@ -227,6 +360,7 @@ impl State {
 #[cfg(test)]
 mod tests {
     use super::{Decodable, Decoder, WireType};
+    use crate::error::Error;
 
     #[test]
     fn decode_uint64_sequence() {
@@ -253,6 +387,159 @@ mod tests {
         assert!(input_ref.is_empty());
     }
 
+    #[test]
+    fn decode_uint64s_in_bulk() {
+        let input = [3, 5, 7, 0x3e, 0x3c, 9];
+        let mut decoder = Decoder::new(WireType::UInt64, 5);
+        let mut output = [0u64; 4];
+
+        // Leaves the truncated value and anything past the sequence alone
+        let mut input_ref = &input[..4];
+        assert_eq!(
+            decoder.decode_uint64s(&mut input_ref, &mut output).unwrap(),
+            3
+        );
+        assert_eq!(&output[..3], &[1, 2, 3]);
+        assert_eq!(input_ref, &[0x3e]);
+
+        let mut input_ref = &input[3..];
+        assert_eq!(
+            decoder.decode_uint64s(&mut input_ref, &mut output).unwrap(),
+            1
+        );
+        assert_eq!(output[0], 0x0f0f);
+        assert_eq!(input_ref, &[9]);
+        assert_eq!(decoder.remaining(), 0);
+    }
+
+    #[test]
+    fn decode_sint64s_in_bulk() {
+        let input = [3, 7, 11];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::SInt64, input.len());
+        let mut output = [0i64; 3];
+
+        assert_eq!(
+            decoder.decode_sint64s(&mut input_ref, &mut output).unwrap(),
+            3
+        );
+        assert_eq!(output, [-1, -2, -3]);
+        assert!(input_ref.is_empty());
+    }
+
+    #[test]
+    fn decode_uint64s_truncated() {
+        let input = [0x3e, 0x3c];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::UInt64, 1);
+        let mut output = [0u64; 2];
+
+        assert_eq!(
+            decoder
+                .decode_uint64s(&mut input_ref, &mut output)
+                .err()
+                .unwrap(),
+            Error::Truncated {
+                remaining: 1,
+                wire_type: WireType::UInt64
+            }
+        );
+        assert_eq!(
+            decoder
+                .decode_uint64s(&mut input_ref, &mut output)
+                .err()
+                .unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_uint64s_wrong_wire_type() {
+        let mut input_ref = &[3, 7, 11][..];
+        let mut decoder = Decoder::new(WireType::SInt64, input_ref.len());
+        assert!(decoder.decode_uint64s(&mut input_ref, &mut [0; 3]).is_err());
+        assert_eq!(
+            decoder.decode_sint64(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_uint64s_after_partial_value() {
+        let input = [0x3e, 0x3c];
+        let mut decoder = Decoder::new(WireType::UInt64, input.len());
+
+        let mut input_ref = &input[..1];
+        assert!(decoder.decode(&mut input_ref).unwrap().is_none());
+
+        let mut input_ref = &input[1..];
+        assert!(decoder.decode_uint64s(&mut input_ref, &mut [0; 1]).is_err());
+        assert_eq!(
+            decoder.decode_uint64(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_after_invalid_string() {
+        let input = [3, 0xff, 7, 98, 97, 122];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::String, input.len());
+
+        assert_eq!(
+            decoder.decode_string(&mut input_ref).err().unwrap(),
+            Error::Utf8 { valid_up_to: 0 }
+        );
+        assert_eq!(
+            decoder.decode_string(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_after_error() {
+        let input = [0x02, 0x00, 3];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::UInt64, input.len());
+        assert!(decoder.decode_uint64(&mut input_ref).is_err());
+
+        let mut input_ref = &input[2..];
+        assert_eq!(
+            decoder.decode_uint64(&mut input_ref).err().unwrap(),
+            Error::Failed
+        );
+    }
+
+    #[test]
+    fn decode_value_past_end_of_sequence() {
+        let input = [3, 0x3e, 0x3c];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::UInt64, 2);
+
+        assert_eq!(1, decoder.decode_uint64(&mut input_ref).unwrap());
+        assert_eq!(decoder.decode(&mut input_ref).err().unwrap(), Error::VInt64);
+
+        // Data following the sequence is never read
+        assert_eq!(input_ref, &[0x3c]);
+        assert_eq!(decoder.decode(&mut input_ref).err().unwrap(), Error::Failed);
+    }
+
+    #[test]
+    fn decode_bytes_past_end_of_sequence() {
+        let input = [11, 98, 121, 116, 101, 115];
+        let mut input_ref = &input[..];
+        let mut decoder = Decoder::new(WireType::Bytes, 3);
+
+        assert_eq!(
+            decoder.decode_bytes(&mut input_ref).err().unwrap(),
+            Error::Truncated {
+                remaining: 3,
+                wire_type: WireType::Bytes
+            }
+        );
+        assert_eq!(input_ref, &[116, 101, 115]);
+    }
+
     #[test]
     fn decode_bytes_sequence() {
         let input = [7, 102, 111, 111, 7, 98, 97, 114, 7, 98, 97, 122];
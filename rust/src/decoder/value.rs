@@ -0,0 +1,51 @@
+//! Decoded field values
+
+use crate::field::WireType;
+
+/// Decoded value of a field, borrowing any dynamically sized data from the
+/// decoder's input
+#[derive(Copy, Clone, Debug, Eq, PartialEq)]
+pub enum Value<'a> {
+    /// Boolean value
+    Bool(bool),
+
+    /// Unsigned 64-bit integer
+    UInt64(u64),
+
+    /// Signed 64-bit integer
+    SInt64(i64),
+
+    /// Binary data
+    Bytes(&'a [u8]),
+
+    /// Unicode string
+    String(&'a str),
+
+    /// Encoded nested message
+    Message(&'a [u8]),
+
+    /// Encoded body of a sequence
+    Sequence {
+        /// Wire type of the values in the sequence
+        wire_type: WireType,
+
+        /// Encoded values in the sequence
+        bytes: &'a [u8],
+    },
+}
+
+impl<'a> Value<'a> {
+    /// Get the [`WireType`] this value is encoded as
+    pub fn wire_type(&self) -> WireType {
+        match self {
+            Value::Bool(false) => WireType::False,
+            Value::Bool(true) => WireType::True,
+            Value::UInt64(_) => WireType::UInt64,
+            Value::SInt64(_) => WireType::SInt64,
+            Value::Bytes(_) => WireType::Bytes,
+            Value::String(_) => WireType::String,
+            Value::Message(_) => WireType::Message,
+            Value::Sequence { .. } => WireType::Sequence,
+        }
+    }
+}
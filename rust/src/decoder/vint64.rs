@@ -3,6 +3,14 @@
 pub(crate) use vint64::signed::zigzag;
 
 use crate::error::Error;
+use core::convert::TryFrom;
+
+/// Convert a decoded `vint64` into a length, rejecting values which don't
+/// fit in a `usize` (i.e. on 16-bit and 32-bit platforms) rather than
+/// silently truncating them
+pub fn length(value: u64) -> Result<usize, Error> {
+    usize::try_from(value).map_err(|_| Error::Length)
+}
 
 /// Decoder for `vint64` values
 #[derive(Clone, Debug, Default)]
@@ -31,13 +39,25 @@ impl Decoder {
         }
 
         if let Some(&first_byte) = input.first() {
-            self.length = Some(vint64::decoded_len(first_byte));
+            let length = vint64::decoded_len(first_byte);
+
+            // Decode straight from the input when it holds the whole value
+            if input.len() >= length {
+                return vint64::decode(input).map(Some).map_err(|_| Error::VInt64);
+            }
+
+            self.length = Some(length);
             self.decode(input)
         } else {
             Ok(None)
         }
     }
 
+    /// Has this decoder consumed part of a `vint64` it hasn't finished decoding?
+    pub fn is_partial(&self) -> bool {
+        self.length.is_some()
+    }
+
     /// Fill the internal buffer with data, returning a [`FieldHeader`] if we're complete
     fn fill_buffer(&mut self, length: usize, input: &mut &[u8]) {
         let remaining = length.checked_sub(self.pos).unwrap();
@@ -1,8 +1,12 @@
 //! Veriform encoder
 
+mod project;
+
+pub use self::project::{project, project_with_limits, Projection};
+
 use crate::{
     error::Error,
-    field::{Header, Tag, WireType},
+    field::{Header, Tag, WireType, MAX_TAG},
     message::Message,
 };
 
@@ -113,6 +117,13 @@ impl<'a> Encoder<'a> {
 
     /// Write a field header to the underlying buffer
     fn write_header(&mut self, tag: Tag, critical: bool, wire_type: WireType) -> Result<(), Error> {
+        if tag > MAX_TAG {
+            return Err(Error::FieldHeader {
+                tag: Some(tag),
+                wire_type: Some(wire_type),
+            });
+        }
+
         self.write(Header::new(tag, critical, wire_type).encode())
     }
 
@@ -146,7 +157,8 @@ mod tests {
     use super::Encoder;
     use crate::{
         decoder::{Decodable, Decoder},
-        field::WireType,
+        error::Error,
+        field::{WireType, MAX_TAG},
     };
 
     const EXAMPLE_BYTES: &[u8] = b"foobar";
@@ -196,4 +208,21 @@ mod tests {
 
         assert!(message.is_empty());
     }
+
+    #[test]
+    fn encode_tag_too_large() {
+        let mut buffer = [0u8; 16];
+        let mut encoder = Encoder::new(&mut buffer);
+
+        encoder.uint64(MAX_TAG, false, 42).unwrap();
+
+        let error = encoder.uint64(MAX_TAG + 1, false, 42).err().unwrap();
+        assert_eq!(
+            error,
+            Error::FieldHeader {
+                tag: Some(MAX_TAG + 1),
+                wire_type: Some(WireType::UInt64)
+            }
+        );
+    }
 }
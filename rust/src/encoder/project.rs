@@ -0,0 +1,177 @@
+//! Re-encoding messages with a subset of their fields
+
+use super::Encoder;
+use crate::{
+    decoder::{Decoder, Limits, Value},
+    error::Error,
+    field::Tag,
+};
+
+/// Fields to select when [`project`]ing a message, given as paths of tags
+/// through nested messages
+#[derive(Copy, Clone, Debug)]
+pub enum Projection<'p> {
+    /// Keep only the selected fields, along with the messages containing them
+    Keep(&'p [&'p [Tag]]),
+
+    /// Keep everything except the selected fields
+    Drop(&'p [&'p [Tag]]),
+}
+
+/// Re-encode `message` into the given encoder, keeping or dropping the
+/// fields selected by the [`Projection`].
+///
+/// Fields which are kept in their entirety are copied verbatim, and the
+/// remaining fields retain their original order. Nested messages are only
+/// re-encoded when a selected path descends into them.
+pub fn project(
+    message: &[u8],
+    projection: Projection<'_>,
+    encoder: &mut Encoder<'_>,
+) -> Result<(), Error> {
+    project_with_limits(message, projection, encoder, Limits::default())
+}
+
+/// Re-encode `message` like [`project`], enforcing the given [`Limits`] on
+/// it and on any nested messages which are re-encoded
+pub fn project_with_limits(
+    message: &[u8],
+    projection: Projection<'_>,
+    encoder: &mut Encoder<'_>,
+    limits: Limits,
+) -> Result<(), Error> {
+    project_fields(message, projection, &[], limits, Some(encoder)).map(|_| ())
+}
+
+/// Project the fields of the message located at the given path prefix,
+/// writing them to the encoder (if any) and returning their encoded length
+fn project_fields(
+    mut input: &[u8],
+    projection: Projection<'_>,
+    prefix: &[Tag],
+    limits: Limits,
+    mut encoder: Option<&mut Encoder<'_>>,
+) -> Result<usize, Error> {
+    let (paths, keep) = match projection {
+        Projection::Keep(paths) => (paths, true),
+        Projection::Drop(paths) => (paths, false),
+    };
+
+    let depth = prefix.len();
+    let mut decoder = Decoder::with_limits(limits);
+    let mut length = 0;
+
+    while !input.is_empty() {
+        let field = input;
+        let header = decoder.decode_header(&mut input)?;
+        let header_len = field.len() - input.len();
+        let value = decoder.decode_value(header.wire_type, &mut input)?;
+        let field = &field[..field.len() - input.len()];
+
+        let mut selected = false;
+        let mut nested_prefix = None;
+
+        for path in paths.iter().filter(|path| {
+            path.len() > depth && &path[..depth] == prefix && path[depth] == header.tag
+        }) {
+            if path.len() == depth + 1 {
+                selected = true;
+            } else {
+                nested_prefix = Some(&path[..=depth]);
+            }
+        }
+
+        match (nested_prefix, value) {
+            (Some(nested_prefix), Value::Message(nested)) if !selected => {
+                let nested_len = project_fields(nested, projection, nested_prefix, limits, None)?;
+                let length_delimiter = vint64::encode(nested_len as u64);
+
+                if let Some(encoder) = &mut encoder {
+                    encoder.write(&field[..header_len])?;
+                    encoder.write(length_delimiter)?;
+                    project_fields(
+                        nested,
+                        projection,
+                        nested_prefix,
+                        limits,
+                        Some(&mut **encoder),
+                    )?;
+                }
+
+                length += header_len + length_delimiter.as_ref().len() + nested_len;
+            }
+            _ => {
+                if selected == keep {
+                    if let Some(encoder) = &mut encoder {
+                        encoder.write(field)?;
+                    }
+
+                    length += field.len();
+                }
+            }
+        }
+    }
+
+    Ok(length)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::{project, project_with_limits, Projection};
+    use crate::{decoder::Limits, encoder::Encoder, error::Error};
+
+    /// Message containing a nested message with fields 2 and 3 in field 1,
+    /// followed by bytes in field 2
+    const EXAMPLE_MESSAGE: &[u8] = &[45, 9, 69, 7, 101, 7, 73, 11, 98, 121, 116, 101, 115];
+
+    fn check(projection: Projection<'_>, expected: &[u8]) {
+        let mut buffer = [0u8; 64];
+        let mut encoder = Encoder::new(&mut buffer);
+        project(EXAMPLE_MESSAGE, projection, &mut encoder).unwrap();
+        assert_eq!(encoder.finish(), expected);
+    }
+
+    #[test]
+    fn keep_fields() {
+        check(Projection::Keep(&[&[2]]), &EXAMPLE_MESSAGE[6..]);
+        check(Projection::Keep(&[&[1], &[2]]), EXAMPLE_MESSAGE);
+        check(Projection::Keep(&[]), &[]);
+    }
+
+    #[test]
+    fn keep_nested_field() {
+        check(Projection::Keep(&[&[1, 3]]), &[45, 5, 101, 7]);
+    }
+
+    #[test]
+    fn drop_fields() {
+        check(Projection::Drop(&[&[1]]), &EXAMPLE_MESSAGE[6..]);
+        check(Projection::Drop(&[]), EXAMPLE_MESSAGE);
+    }
+
+    #[test]
+    fn drop_nested_field() {
+        check(
+            Projection::Drop(&[&[1, 2]]),
+            &[45, 5, 101, 7, 73, 11, 98, 121, 116, 101, 115],
+        );
+    }
+
+    #[test]
+    fn project_over_limit() {
+        let mut buffer = [0u8; 64];
+        let mut encoder = Encoder::new(&mut buffer);
+        let limits = Limits {
+            max_bytes_len: 4,
+            ..Limits::default()
+        };
+
+        let result = project_with_limits(
+            EXAMPLE_MESSAGE,
+            Projection::Keep(&[&[1]]),
+            &mut encoder,
+            limits,
+        );
+        assert_eq!(result.err().unwrap(), Error::Length);
+    }
+}
@@ -21,6 +21,12 @@ pub enum Error {
         wire_type: WireType,
     },
 
+    /// field {tag:?} is duplicated
+    Duplicate {
+        /// tag of the duplicated field
+        tag: Tag,
+    },
+
     /// operation failed
     Failed,
 
@@ -8,3 +8,7 @@ pub use self::{header::Header, wire_type::WireType};
 
 /// Tag which identifies a field
 pub type Tag = u64;
+
+/// Maximum value of a [`Tag`]: the field header reserves the low four bits
+/// for the critical bit and wire type, leaving 60 bits for the tag
+pub const MAX_TAG: Tag = (1 << 60) - 1;
@@ -1,7 +1,10 @@
 //! Veriform wire types
 
 pub use crate::error::Error;
-use core::convert::TryFrom;
+use core::{
+    convert::TryFrom,
+    fmt::{self, Display},
+};
 
 /// Wire type identifiers for Veriform types
 #[derive(Copy, Clone, Debug, Eq, PartialEq, PartialOrd, Ord)]
@@ -48,6 +51,21 @@ impl WireType {
     }
 }
 
+impl Display for WireType {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            WireType::False => "false",
+            WireType::True => "true",
+            WireType::UInt64 => "uint64",
+            WireType::SInt64 => "sint64",
+            WireType::Bytes => "bytes",
+            WireType::String => "string",
+            WireType::Message => "message",
+            WireType::Sequence => "sequence",
+        })
+    }
+}
+
 impl TryFrom<u64> for WireType {
     type Error = Error;
 
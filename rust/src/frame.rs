@@ -0,0 +1,346 @@
+//! Framing for exchanging Veriform messages over byte streams.
+//!
+//! Each frame consists of a `vint64` length prefix followed by an encoded
+//! message of that length.
+
+use crate::{error::Error, message::Message};
+use std::{
+    io::{self, Read, Write},
+    time::{Duration, Instant},
+    vec::Vec,
+};
+
+/// Reads length-prefixed Veriform messages from an underlying [`Read`].
+///
+/// A read timeout on the underlying stream (e.g. `TcpStream::set_read_timeout`)
+/// keeps a stalled peer from blocking any single read forever, but a peer
+/// which trickles in a byte just before each timeout can still hold a frame
+/// open indefinitely. To bound the time taken by the whole frame, also set a
+/// frame timeout with [`Reader::set_frame_timeout`]. It's checked after each
+/// read, so with both set a frame takes at most the frame timeout plus one
+/// read timeout.
+///
+/// A timeout between frames is returned without consuming anything and
+/// reading can be retried. Any error in the middle of a frame leaves the
+/// stream out of sync, so all later reads fail.
+pub struct Reader<R: Read> {
+    /// Underlying reader
+    reader: R,
+
+    /// Maximum length of a frame (sans length prefix)
+    max_len: usize,
+
+    /// Buffer containing the most recently read frame
+    buffer: Vec<u8>,
+
+    /// Maximum time to spend reading a frame after its first byte arrives
+    frame_timeout: Option<Duration>,
+
+    /// Did an error occur partway through reading a frame?
+    failed: bool,
+}
+
+impl<R: Read> Reader<R> {
+    /// Create a new frame reader which rejects frames longer than `max_len`
+    pub fn new(reader: R, max_len: usize) -> Self {
+        Self {
+            reader,
+            max_len,
+            buffer: Vec::new(),
+            frame_timeout: None,
+            failed: false,
+        }
+    }
+
+    /// Set the maximum time to spend reading a frame, measured from the
+    /// arrival of its first byte. Frames which take longer fail with
+    /// [`io::ErrorKind::TimedOut`]. `None` (the default) disables the limit.
+    pub fn set_frame_timeout(&mut self, timeout: Option<Duration>) {
+        self.frame_timeout = timeout;
+    }
+
+    /// Read the next frame, returning `None` if the stream ended cleanly
+    /// at a frame boundary
+    pub fn read_frame(&mut self) -> io::Result<Option<&[u8]>> {
+        if self.failed {
+            return Err(io::Error::new(io::ErrorKind::Other, Error::Failed));
+        }
+
+        let first_byte = match self.read_first_byte()? {
+            Some(byte) => byte,
+            None => return Ok(None),
+        };
+
+        let deadline = self
+            .frame_timeout
+            .and_then(|timeout| Instant::now().checked_add(timeout));
+
+        if let Err(e) = self.read_remaining(first_byte, deadline) {
+            self.failed = true;
+            return Err(e);
+        }
+
+        Ok(Some(&self.buffer))
+    }
+
+    /// Read the next frame and decode it as a message of type `M`
+    pub fn read_message<M: Message>(&mut self) -> io::Result<Option<M>> {
+        match self.read_frame()? {
+            Some(frame) => M::decode(frame).map(Some).map_err(invalid_data),
+            None => Ok(None),
+        }
+    }
+
+    /// Consume this frame reader, returning the underlying reader
+    pub fn into_inner(self) -> R {
+        self.reader
+    }
+
+    /// Read the first byte of a frame, returning `None` at end of stream
+    fn read_first_byte(&mut self) -> io::Result<Option<u8>> {
+        let mut byte = [0u8];
+
+        loop {
+            match self.reader.read(&mut byte) {
+                Ok(0) => return Ok(None),
+                Ok(_) => return Ok(Some(byte[0])),
+                Err(e) if e.kind() == io::ErrorKind::Interrupted => continue,
+                Err(e) => return Err(e),
+            }
+        }
+    }
+
+    /// Read the remainder of a frame after its first byte into the buffer
+    fn read_remaining(&mut self, first_byte: u8, deadline: Option<Instant>) -> io::Result<()> {
+        let mut prefix = [0u8; vint64::MAX_BYTES];
+        let prefix_len = vint64::decoded_len(first_byte);
+        prefix[0] = first_byte;
+        read_full(&mut self.reader, &mut prefix[1..prefix_len], deadline)?;
+
+        let mut prefix_ref = &prefix[..prefix_len];
+        let length = vint64::decode(&mut prefix_ref).map_err(|_| invalid_data(Error::VInt64))?;
+
+        if length > self.max_len as u64 {
+            return Err(invalid_data(Error::Length));
+        }
+
+        self.buffer.resize(length as usize, 0);
+        read_full(&mut self.reader, &mut self.buffer, deadline)
+    }
+}
+
+/// Fill `buf` from the given reader, failing if the deadline (if any) passes
+/// before it's full
+fn read_full(reader: &mut impl Read, buf: &mut [u8], deadline: Option<Instant>) -> io::Result<()> {
+    let mut filled = 0;
+
+    while filled < buf.len() {
+        match reader.read(&mut buf[filled..]) {
+            Ok(0) => return Err(io::ErrorKind::UnexpectedEof.into()),
+            Ok(n) => filled += n,
+            Err(e) if e.kind() == io::ErrorKind::Interrupted => (),
+            Err(e) => return Err(e),
+        }
+
+        if let Some(deadline) = deadline {
+            if filled < buf.len() && Instant::now() >= deadline {
+                return Err(io::ErrorKind::TimedOut.into());
+            }
+        }
+    }
+
+    Ok(())
+}
+
+/// Writes length-prefixed Veriform messages to an underlying [`Write`]
+pub struct Writer<W: Write> {
+    /// Underlying writer
+    writer: W,
+
+    /// Buffer reused for encoding messages
+    buffer: Vec<u8>,
+}
+
+impl<W: Write> Writer<W> {
+    /// Create a new frame writer
+    pub fn new(writer: W) -> Self {
+        Self {
+            writer,
+            buffer: Vec::new(),
+        }
+    }
+
+    /// Write an already encoded message as a frame
+    pub fn write_frame(&mut self, frame: &[u8]) -> io::Result<()> {
+        self.writer
+            .write_all(vint64::encode(frame.len() as u64).as_ref())?;
+        self.writer.write_all(frame)
+    }
+
+    /// Encode the given message and write it as a frame
+    pub fn write_message(&mut self, message: &dyn Message) -> io::Result<()> {
+        self.buffer.clear();
+        message
+            .encode_append(&mut self.buffer)
+            .map_err(invalid_data)?;
+
+        self.writer
+            .write_all(vint64::encode(self.buffer.len() as u64).as_ref())?;
+        self.writer.write_all(&self.buffer)
+    }
+
+    /// Flush the underlying writer
+    pub fn flush(&mut self) -> io::Result<()> {
+        self.writer.flush()
+    }
+
+    /// Consume this frame writer, returning the underlying writer
+    pub fn into_inner(self) -> W {
+        self.writer
+    }
+}
+
+/// Convert a Veriform error into an I/O error
+fn invalid_data(error: Error) -> io::Error {
+    io::Error::new(io::ErrorKind::InvalidData, error)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::{Reader, Writer};
+    use crate::{encoder::Encoder, error::Error, field, message::Message};
+    use std::{
+        io::{self, Read},
+        time::Duration,
+        vec::Vec,
+    };
+
+    const EXAMPLE_FRAMES: &[&[u8]] = &[&[138, 10, 85], &[], &[73, 11, 98, 121, 116, 101, 115]];
+
+    #[test]
+    fn write_then_read() {
+        let mut writer = Writer::new(Vec::new());
+
+        for frame in EXAMPLE_FRAMES {
+            writer.write_frame(frame).unwrap();
+        }
+
+        let encoded = writer.into_inner();
+        let mut reader = Reader::new(encoded.as_slice(), 16);
+
+        for &frame in EXAMPLE_FRAMES {
+            assert_eq!(reader.read_frame().unwrap().unwrap(), frame);
+        }
+
+        assert!(reader.read_frame().unwrap().is_none());
+    }
+
+    /// Message containing a single `uint64` field
+    struct Counter(u64);
+
+    impl Message for Counter {
+        fn decode(_bytes: impl AsRef<[u8]>) -> Result<Self, Error> {
+            unimplemented!();
+        }
+
+        fn encode<'a>(&self, buffer: &'a mut [u8]) -> Result<&'a [u8], Error> {
+            let mut encoder = Encoder::new(buffer);
+            encoder.uint64(1, false, self.0)?;
+            Ok(encoder.finish())
+        }
+
+        fn encoded_len(&self) -> usize {
+            field::length::uint64(1, self.0)
+        }
+    }
+
+    #[test]
+    fn write_messages() {
+        let mut writer = Writer::new(Vec::new());
+        writer.write_message(&Counter(0x0f0f)).unwrap();
+        writer.write_message(&Counter(42)).unwrap();
+        assert_eq!(writer.into_inner(), [7, 37, 62, 60, 5, 37, 85]);
+    }
+
+    #[test]
+    fn read_oversized_frame() {
+        let mut writer = Writer::new(Vec::new());
+        writer.write_frame(&[0u8; 17]).unwrap();
+
+        let encoded = writer.into_inner();
+        let mut reader = Reader::new(encoded.as_slice(), 16);
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::InvalidData);
+    }
+
+    #[test]
+    fn read_truncated_frame() {
+        let encoded = [7, 102, 111];
+        let mut reader = Reader::new(&encoded[..], 16);
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::UnexpectedEof);
+    }
+
+    /// Reader which times out after returning the given data
+    struct TimeoutReader<'a>(&'a [u8]);
+
+    impl<'a> Read for TimeoutReader<'a> {
+        fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+            if self.0.is_empty() {
+                return Err(io::ErrorKind::TimedOut.into());
+            }
+
+            self.0.read(buf)
+        }
+    }
+
+    #[test]
+    fn timeout_between_frames() {
+        let mut reader = Reader::new(TimeoutReader(&[]), 16);
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::TimedOut);
+
+        // Nothing was consumed, so the reader can still be used
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::TimedOut);
+    }
+
+    /// Reader which returns at most one byte per read
+    struct DripReader<'a>(&'a [u8]);
+
+    impl<'a> Read for DripReader<'a> {
+        fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+            let len = buf.len().min(1);
+            self.0.read(&mut buf[..len])
+        }
+    }
+
+    #[test]
+    fn read_frame_in_pieces() {
+        let mut reader = Reader::new(DripReader(&[7, 102, 111, 111]), 16);
+        reader.set_frame_timeout(Some(Duration::from_secs(60)));
+        assert_eq!(reader.read_frame().unwrap().unwrap(), b"foo");
+    }
+
+    #[test]
+    fn frame_timeout() {
+        let mut reader = Reader::new(DripReader(&[7, 102, 111, 111]), 16);
+        reader.set_frame_timeout(Some(Duration::from_secs(0)));
+
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::TimedOut);
+
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::Other);
+    }
+
+    #[test]
+    fn timeout_mid_frame() {
+        let mut reader = Reader::new(TimeoutReader(&[7, 102]), 16);
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::TimedOut);
+
+        let error = reader.read_frame().err().unwrap();
+        assert_eq!(error.kind(), io::ErrorKind::Other);
+    }
+}
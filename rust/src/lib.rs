@@ -22,6 +22,8 @@ pub mod decoder;
 pub mod encoder;
 pub mod error;
 pub mod field;
+#[cfg(feature = "std")]
+pub mod frame;
 pub mod message;
 
 pub use crate::{
@@ -47,4 +47,59 @@ pub trait Message {
         self.encode(&mut encoded)?;
         Ok(encoded)
     }
+
+    /// Encode this message as Veriform, appending it to the provided vector.
+    ///
+    /// Reusing the same vector when encoding many messages avoids making an
+    /// allocation per message. On error the vector is left unchanged.
+    #[cfg(feature = "alloc")]
+    fn encode_append(&self, buffer: &mut Vec<u8>) -> Result<(), Error> {
+        let start = buffer.len();
+        buffer.resize(start.checked_add(self.encoded_len()).unwrap(), 0);
+
+        let length = match self.encode(&mut buffer[start..]) {
+            Ok(encoded) => encoded.len(),
+            Err(e) => {
+                buffer.truncate(start);
+                return Err(e);
+            }
+        };
+
+        // Only keep what was actually encoded, in case `encoded_len` overestimates
+        buffer.truncate(start.checked_add(length).unwrap());
+        Ok(())
+    }
+}
+
+#[cfg(all(test, feature = "alloc"))]
+mod tests {
+    use super::Message;
+    use crate::{Encoder, Error};
+    use alloc::vec::Vec;
+
+    /// Message whose `encoded_len` overestimates its length
+    struct Padded;
+
+    impl Message for Padded {
+        fn decode(_bytes: impl AsRef<[u8]>) -> Result<Self, Error> {
+            Ok(Padded)
+        }
+
+        fn encode<'a>(&self, buffer: &'a mut [u8]) -> Result<&'a [u8], Error> {
+            let mut encoder = Encoder::new(buffer);
+            encoder.uint64(1, false, 42)?;
+            Ok(encoder.finish())
+        }
+
+        fn encoded_len(&self) -> usize {
+            16
+        }
+    }
+
+    #[test]
+    fn encode_append_keeps_only_encoded_bytes() {
+        let mut buffer = Vec::from(&[1, 2, 3][..]);
+        Padded.encode_append(&mut buffer).unwrap();
+        assert_eq!(buffer, [1, 2, 3, 37, 85]);
+    }
 }
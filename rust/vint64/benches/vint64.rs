@@ -46,6 +46,24 @@ fn bench(c: &mut Criterion<CyclesPerByte>) {
         });
     });
 
+    group.bench_function("decode (with trailing data)", |b| {
+        let mut examples = [[0u8; 16]; 8];
+
+        for (example, &value) in examples.iter_mut().zip(EXAMPLE_VALUES.iter()) {
+            let encoded = vint64::encode(value);
+            let encoded = encoded.as_ref();
+            example[..encoded.len()].copy_from_slice(encoded);
+        }
+
+        let mut n = 0;
+
+        b.iter(|| {
+            let mut slice = examples[n].as_ref();
+            vint64::decode(&mut slice).unwrap();
+            n = (n + 1) & 0x07;
+        });
+    });
+
     group.finish();
 }
 
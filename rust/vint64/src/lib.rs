@@ -194,6 +194,11 @@ pub fn decode(input: &mut &[u8]) -> Result<u64, Error> {
     let result = if length == 9 {
         // 9-byte special case
         u64::from_le_bytes(bytes[1..9].try_into().unwrap())
+    } else if bytes.len() >= 8 {
+        // Fast path: load a whole word and discard the bytes past the end
+        // of the value rather than copying it into a scratch buffer
+        let shift = 64 - 8 * length;
+        (u64::from_le_bytes(bytes[..8].try_into().unwrap()) << shift) >> (shift + length)
     } else {
         let mut encoded = [0u8; 8];
         encoded[..length].copy_from_slice(&bytes[..length]);
@@ -209,9 +214,44 @@ pub fn decode(input: &mut &[u8]) -> Result<u64, Error> {
     }
 }
 
+/// Decode a run of `vint64`-encoded unsigned 64-bit integers into `output`,
+/// returning the number of values decoded.
+///
+/// Decoding stops when `output` is full, or when `input` doesn't begin with
+/// a complete `vint64` (i.e. it's empty or ends with a truncated value).
+/// Upon success, the reference is updated to begin at the byte immediately
+/// after the last decoded `vint64`.
+///
+/// If a malformed value is encountered, an error is returned and the
+/// reference is left pointing at it.
+pub fn decode_slice(input: &mut &[u8], output: &mut [u64]) -> Result<usize, Error> {
+    decode_run(input, output, decode)
+}
+
+/// Decode values into `output` with the given function until it's full or
+/// `input` no longer begins with a complete `vint64`
+pub(crate) fn decode_run<T>(
+    input: &mut &[u8],
+    output: &mut [T],
+    decode: impl Fn(&mut &[u8]) -> Result<T, Error>,
+) -> Result<usize, Error> {
+    let mut count = 0;
+
+    for value in output.iter_mut() {
+        match input.first() {
+            Some(&byte) if input.len() >= decoded_len(byte) => *value = decode(input)?,
+            _ => break,
+        }
+
+        count += 1;
+    }
+
+    Ok(count)
+}
+
 #[cfg(test)]
 mod tests {
-    use super::{decode, encode, signed};
+    use super::{decode, decode_slice, encode, signed};
 
     #[test]
     fn encode_zero() {
@@ -292,6 +332,30 @@ mod tests {
         assert_eq!(slice, &[0xde, 0xad, 0xbe, 0xef]);
     }
 
+    #[test]
+    fn decode_fast_path_matches_slow_path() {
+        for &value in &[
+            0,
+            0x0f,
+            0x0f0f,
+            0x0f0f_f0f0,
+            0x0f0f_f0f0_0f0f,
+            1 << 50,
+            core::u64::MAX,
+        ] {
+            let encoded = encode(value);
+            let encoded = encoded.as_ref();
+
+            // Pad with trailing data so the fast path can read a whole word
+            let mut padded = [0xffu8; 16];
+            padded[..encoded.len()].copy_from_slice(encoded);
+
+            let mut slice = &padded[..];
+            assert_eq!(decode(&mut slice).unwrap(), value);
+            assert_eq!(slice.len(), 16 - encoded.len());
+        }
+    }
+
     #[test]
     fn decode_truncated() {
         let mut slice = [0].as_ref();
@@ -307,6 +371,36 @@ mod tests {
         assert!(decode(&mut slice).is_err());
     }
 
+    #[test]
+    fn decode_slice_values() {
+        let mut slice = [0x55, 0x3e, 0x3c, 0x01, 0x08, 0x0f].as_ref();
+        let mut output = [0u64; 4];
+
+        // Stops before the truncated trailing value
+        assert_eq!(decode_slice(&mut slice, &mut output).unwrap(), 3);
+        assert_eq!(&output[..3], &[42, 0x0f0f, 0]);
+        assert_eq!(slice, &[0x08, 0x0f]);
+    }
+
+    #[test]
+    fn decode_slice_output_full() {
+        let mut slice = [0x55, 0x3e, 0x3c, 0x01].as_ref();
+        let mut output = [0u64; 2];
+
+        assert_eq!(decode_slice(&mut slice, &mut output).unwrap(), 2);
+        assert_eq!(output, [42, 0x0f0f]);
+        assert_eq!(slice, &[0x01]);
+    }
+
+    #[test]
+    fn decode_slice_malformed() {
+        let mut slice = [0x55, 0x08, 0x00, 0x00, 0x00].as_ref();
+        let mut output = [0u64; 2];
+
+        assert!(decode_slice(&mut slice, &mut output).is_err());
+        assert_eq!(slice, &[0x08, 0x00, 0x00, 0x00]);
+    }
+
     #[test]
     fn decode_signed_values() {
         let mut slice = [0x10, 0x3c, 0xfc, 0xc3, 0x03].as_ref();
@@ -315,4 +409,14 @@ mod tests {
         let mut slice = [0xf0, 0x3b, 0xfc, 0xc3, 0x03].as_ref();
         assert_eq!(signed::decode(&mut slice).unwrap(), -0x0f0f_f0f0);
     }
+
+    #[test]
+    fn decode_signed_slice_values() {
+        let mut slice = [0xa7, 0x03, 0x07].as_ref();
+        let mut output = [0i64; 3];
+
+        assert_eq!(signed::decode_slice(&mut slice, &mut output).unwrap(), 3);
+        assert_eq!(output, [-42, -1, -2]);
+        assert!(slice.is_empty());
+    }
 }
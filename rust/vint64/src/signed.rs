@@ -12,6 +12,14 @@ pub fn decode(input: &mut &[u8]) -> Result<i64, Error> {
     super::decode(input).map(zigzag::decode)
 }
 
+/// Decode a run of zigzag-encoded `vint64` values into `output`, returning
+/// the number of values decoded.
+///
+/// See [`vint64::decode_slice`](../fn.decode_slice.html) for details.
+pub fn decode_slice(input: &mut &[u8], output: &mut [i64]) -> Result<usize, Error> {
+    super::decode_run(input, output, decode)
+}
+
 /// Get the length of a zigzag encoded `vint64` for the given value in bytes.
 pub fn encoded_len(value: i64) -> usize {
     super::encoded_len(zigzag::encode(value))